@@ -0,0 +1,338 @@
+package entitycoll
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Authenticator identifies the Entity making a request, or returns an
+// error if it cannot. Returning an error only means this Authenticator
+// found no credentials it understands or rejected the ones it found;
+// applySecurity tries every registered Authenticator in order before
+// giving up and responding 401.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Entity, error)
+}
+
+// ErrNoCredentials is returned by an Authenticator when the request
+// carries none of the credentials it knows how to check (e.g. no
+// Authorization header), as distinct from credentials it checked and
+// rejected. applySecurity treats both cases the same way (try the next
+// Authenticator), but built-in Authenticators return this sentinel so
+// callers composing their own chains can tell the two apart.
+var ErrNoCredentials = errors.New("entitycoll: no credentials present")
+
+// challenger is implemented by Authenticators that can describe
+// themselves in a WWW-Authenticate header, used to build that header
+// when every Authenticator in the chain fails.
+type challenger interface {
+	challenge() string
+}
+
+// globalAuthenticators is the default chain tried for collections with
+// no override registered via RegisterCollectionAuthenticators.
+var globalAuthenticators []Authenticator
+
+// collectionAuthenticators holds per-EntityCollection overrides of the
+// authenticator chain, set via RegisterCollectionAuthenticators. A
+// collection registered here with an empty slice is public.
+var collectionAuthenticators = map[EntityCollection][]Authenticator{}
+
+// RegisterAuthenticator appends an Authenticator to the default chain
+// used by every EntityCollection that has no collection-specific
+// override. Authenticators are tried in registration order.
+func RegisterAuthenticator(a Authenticator) {
+	globalAuthenticators = append(globalAuthenticators, a)
+}
+
+// RegisterCollectionAuthenticators overrides the authenticator chain
+// used for ec, replacing the default chain entirely. Passing no
+// authenticators makes ec public (every request authenticates as a nil
+// Entity).
+func RegisterCollectionAuthenticators(ec EntityCollection, authenticators ...Authenticator) {
+	collectionAuthenticators[ec] = authenticators
+}
+
+// authenticatorsFor returns the chain that applies to ec: its
+// collection-specific override if one was registered, otherwise the
+// default chain.
+func authenticatorsFor(ec EntityCollection) ([]Authenticator, bool) {
+	if chain, ok := collectionAuthenticators[ec]; ok {
+		return chain, true
+	}
+	return globalAuthenticators, false
+}
+
+// BasicAuthenticator implements HTTP Basic Authentication, looking up
+// the Entity for a username/password pair via Lookup. It reproduces the
+// module's original (pre-Authenticator) behavior.
+type BasicAuthenticator struct {
+	Lookup func(uname, pwd string) (Entity, error)
+}
+
+func (b *BasicAuthenticator) Authenticate(r *http.Request) (Entity, error) {
+	uname, pwd, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	return b.Lookup(uname, pwd)
+}
+
+func (b *BasicAuthenticator) challenge() string {
+	return `Basic realm="a"`
+}
+
+// BearerAuthenticator implements Bearer-token authentication, verifying
+// a JWT's signature against Secret (HMAC-SHA256) and mapping the claim
+// named Claim to an Entity via Lookup. It is intentionally minimal: it
+// only supports the HS256 alg, which is all the module needs without
+// pulling in a full JWT library.
+type BearerAuthenticator struct {
+	Secret []byte
+	Claim  string
+	Lookup func(claimValue string) (Entity, error)
+}
+
+func (b *BearerAuthenticator) Authenticate(r *http.Request) (Entity, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, ErrNoCredentials
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+
+	claims, err := verifyHS256(token, b.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	claimValue, ok := claims[b.Claim].(string)
+	if !ok {
+		return nil, errors.New("entitycoll: JWT missing claim " + b.Claim)
+	}
+	return b.Lookup(claimValue)
+}
+
+func (b *BearerAuthenticator) challenge() string {
+	return "Bearer"
+}
+
+// Scopes implements ScopeExtractor by re-reading the "scope" claim
+// (a space-separated string, per RFC 6749 section 3.3) from the
+// token's already-verified payload.
+func (b *BearerAuthenticator) Scopes(r *http.Request, requestor Entity) []string {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	claims, err := verifyHS256(token, b.Secret)
+	if err != nil {
+		return nil
+	}
+	scopeClaim, _ := claims["scope"].(string)
+	if scopeClaim == "" {
+		return nil
+	}
+	return strings.Fields(scopeClaim)
+}
+
+// verifyHS256 checks the signature of an HS256-signed JWT against
+// secret, checks its exp/nbf claims (when present) against the current
+// time, and returns its decoded claim set.
+func verifyHS256(token string, secret []byte) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("entitycoll: malformed JWT")
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	var headerFields struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &headerFields); err != nil {
+		return nil, err
+	}
+	if headerFields.Alg != "HS256" {
+		return nil, errors.New("entitycoll: unsupported JWT alg " + headerFields.Alg)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	expectedSig := mac.Sum(nil)
+
+	gotSig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(expectedSig, gotSig) {
+		return nil, errors.New("entitycoll: invalid JWT signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if raw, ok := claims["exp"]; ok {
+		exp, ok := jwtNumericDate(raw)
+		if !ok {
+			return nil, errors.New("entitycoll: JWT exp claim is not a number")
+		}
+		if !now.Before(exp) {
+			return nil, errors.New("entitycoll: JWT has expired")
+		}
+	}
+	if raw, ok := claims["nbf"]; ok {
+		nbf, ok := jwtNumericDate(raw)
+		if !ok {
+			return nil, errors.New("entitycoll: JWT nbf claim is not a number")
+		}
+		if now.Before(nbf) {
+			return nil, errors.New("entitycoll: JWT not yet valid")
+		}
+	}
+
+	return claims, nil
+}
+
+// jwtNumericDate converts a decoded exp/nbf claim (a JSON number,
+// always a float64 once unmarshaled into map[string]interface{}, per
+// RFC 7519 section 2's NumericDate -- seconds since the Unix epoch)
+// into a time.Time.
+func jwtNumericDate(raw interface{}) (time.Time, bool) {
+	seconds, ok := raw.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// OAuth2IntrospectionAuthenticator authenticates a Bearer token by
+// posting it to an RFC 7662 token introspection endpoint and mapping
+// the resulting claims to an Entity via Lookup.
+type OAuth2IntrospectionAuthenticator struct {
+	IntrospectionURL string
+	ClientID         string
+	ClientSecret     string
+	Lookup           func(claims map[string]interface{}) (Entity, error)
+
+	// Client is used to make the introspection request; defaults to
+	// http.DefaultClient when nil.
+	Client *http.Client
+}
+
+func (o *OAuth2IntrospectionAuthenticator) Authenticate(r *http.Request) (Entity, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, ErrNoCredentials
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.PostForm(o.IntrospectionURL, url.Values{
+		"token":           {token},
+		"client_id":       {o.ClientID},
+		"client_secret":   {o.ClientSecret},
+		"token_type_hint": {"access_token"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var introspection map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&introspection); err != nil {
+		return nil, err
+	}
+
+	active, _ := introspection["active"].(bool)
+	if !active {
+		return nil, errors.New("entitycoll: token is not active")
+	}
+	return o.Lookup(introspection)
+}
+
+func (o *OAuth2IntrospectionAuthenticator) challenge() string {
+	return "Bearer"
+}
+
+// applySecurity wraps handler so that requests for ec are authenticated
+// before being dispatched: each Authenticator in ec's chain (see
+// authenticatorsFor) is tried in order, and the Entity from the first
+// one that succeeds is stashed on the request context for
+// getRequestorFromRequest. If every Authenticator fails, the response is
+// 401 with a WWW-Authenticate header built from whichever Authenticators
+// can describe a challenge.
+func applySecurity(ec EntityCollection, handler http.Handler) http.Handler {
+	securityHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		chain, _ := authenticatorsFor(ec)
+
+		var requestor Entity
+		var authenticated bool
+		var usedAuthenticator Authenticator
+		for _, a := range chain {
+			entity, err := a.Authenticate(r)
+			if err == nil {
+				requestor = entity
+				usedAuthenticator = a
+				authenticated = true
+				break
+			}
+		}
+
+		if !authenticated {
+			if len(chain) == 0 {
+				// no authenticators apply (a collection explicitly
+				// registered with none, or no default chain registered
+				// at all): the collection is public
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			for _, a := range chain {
+				if c, ok := a.(challenger); ok {
+					w.Header().Add("WWW-Authenticate", c.challenge())
+				}
+			}
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := withRequestor(r.Context(), requestor)
+		if se, ok := usedAuthenticator.(ScopeExtractor); ok {
+			ctx = withScopes(ctx, se.Scopes(r, requestor))
+		}
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(securityHandler)
+}
+
+// SetRequestorAuthFn is retained for backwards compatibility: it
+// installs raf as a BasicAuthenticator at the front of the default
+// authenticator chain, reproducing the module's original Basic-only
+// behavior for callers that haven't adopted RegisterAuthenticator yet.
+func SetRequestorAuthFn(raf func(uname, pwd string) (Entity, error)) {
+	getRequestor = raf
+	globalAuthenticators = append([]Authenticator{&BasicAuthenticator{Lookup: raf}}, globalAuthenticators...)
+}
@@ -0,0 +1,82 @@
+package entitycoll
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// signHS256 builds a minimal HS256 JWT with the given claims, for
+// exercising verifyHS256 without a JWT library.
+func signHS256(t *testing.T, secret []byte, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		t.Fatalf("marshaling header: %v", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshaling claims: %v", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	return signingInput + "." + sig
+}
+
+func TestVerifyHS256RejectsExpiredToken(t *testing.T) {
+	secret := []byte("test-secret")
+	token := signHS256(t, secret, map[string]interface{}{
+		"sub": "alice",
+		"exp": float64(time.Now().Add(-time.Hour).Unix()),
+	})
+
+	if _, err := verifyHS256(token, secret); err == nil {
+		t.Error("verifyHS256: expected error for expired token, got nil")
+	}
+}
+
+func TestVerifyHS256RejectsNotYetValidToken(t *testing.T) {
+	secret := []byte("test-secret")
+	token := signHS256(t, secret, map[string]interface{}{
+		"sub": "alice",
+		"nbf": float64(time.Now().Add(time.Hour).Unix()),
+	})
+
+	if _, err := verifyHS256(token, secret); err == nil {
+		t.Error("verifyHS256: expected error for not-yet-valid token, got nil")
+	}
+}
+
+func TestVerifyHS256AcceptsValidToken(t *testing.T) {
+	secret := []byte("test-secret")
+	token := signHS256(t, secret, map[string]interface{}{
+		"sub": "alice",
+		"exp": float64(time.Now().Add(time.Hour).Unix()),
+		"nbf": float64(time.Now().Add(-time.Hour).Unix()),
+	})
+
+	claims, err := verifyHS256(token, secret)
+	if err != nil {
+		t.Fatalf("verifyHS256: unexpected error: %v", err)
+	}
+	if claims["sub"] != "alice" {
+		t.Errorf("verifyHS256: claims[sub] = %v, want alice", claims["sub"])
+	}
+}
+
+func TestVerifyHS256RejectsBadSignature(t *testing.T) {
+	token := signHS256(t, []byte("right-secret"), map[string]interface{}{"sub": "alice"})
+
+	if _, err := verifyHS256(token, []byte("wrong-secret")); err == nil {
+		t.Error("verifyHS256: expected error for signature mismatch, got nil")
+	}
+}
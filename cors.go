@@ -0,0 +1,120 @@
+package entitycoll
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig describes the Cross-Origin Resource Sharing policy applied
+// to an EntityCollection's handlers. The zero value allows no origins,
+// so a policy must be installed via SetCORSConfig or WithCORS before an
+// EntityCollection is reachable from a browser.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins permitted to make requests. An
+	// entry of "*" matches any origin. An entry prefixed "re:" is
+	// compiled as a regexp and matched against the Origin header.
+	AllowedOrigins []string
+
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposedHeaders []string
+
+	AllowCredentials bool
+
+	// MaxAge is the preflight cache lifetime, in seconds. Zero omits
+	// the Access-Control-Max-Age header.
+	MaxAge int
+}
+
+// globalCORSConfig is applied to every EntityCollection with no
+// collection-specific override registered via WithCORS.
+var globalCORSConfig CORSConfig
+
+// collectionCORSConfig holds per-EntityCollection overrides of
+// globalCORSConfig, set via WithCORS.
+var collectionCORSConfig = map[EntityCollection]CORSConfig{}
+
+// SetCORSConfig installs cfg as the default CORS policy for every
+// EntityCollection that has no override registered via WithCORS.
+func SetCORSConfig(cfg CORSConfig) {
+	globalCORSConfig = cfg
+}
+
+// WithCORS overrides the CORS policy used for ec, replacing
+// globalCORSConfig for that collection only.
+func WithCORS(ec EntityCollection, cfg CORSConfig) {
+	collectionCORSConfig[ec] = cfg
+}
+
+func corsConfigFor(ec EntityCollection) CORSConfig {
+	if cfg, ok := collectionCORSConfig[ec]; ok {
+		return cfg
+	}
+	return globalCORSConfig
+}
+
+// originAllowed reports whether origin matches one of allowed, which
+// may contain exact origins, "*", or "re:"-prefixed regexps.
+func originAllowed(origin string, allowed []string) bool {
+	for _, a := range allowed {
+		switch {
+		case a == "*":
+			return true
+		case strings.HasPrefix(a, "re:"):
+			re, err := regexp.Compile(strings.TrimPrefix(a, "re:"))
+			if err == nil && re.MatchString(origin) {
+				return true
+			}
+		case a == origin:
+			return true
+		}
+	}
+	return false
+}
+
+// applyCorsHeaders wraps handler with the CORS policy registered for
+// ec (see SetCORSConfig/WithCORS), echoing the request Origin back only
+// when it matches that policy's AllowedOrigins, and answering arbitrary
+// preflight requests according to AllowedMethods/AllowedHeaders/MaxAge.
+func applyCorsHeaders(ec EntityCollection, handler http.Handler) http.Handler {
+	corsHandler := func(w http.ResponseWriter, r *http.Request) {
+		cfg := corsConfigFor(ec)
+		origin := r.Header.Get("Origin")
+
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || !originAllowed(origin, cfg.AllowedOrigins) {
+			if r.Method != http.MethodOptions {
+				handler.ServeHTTP(w, r)
+			}
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if cfg.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method == http.MethodOptions {
+			if len(cfg.AllowedMethods) > 0 {
+				w.Header().Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+			}
+			if len(cfg.AllowedHeaders) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+			}
+			if cfg.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if len(cfg.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+		}
+		handler.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(corsHandler)
+}
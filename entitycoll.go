@@ -7,30 +7,18 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/satori/go.uuid"
 )
 
-// takes a route to an entity collection and an entity collection
-// and sets up handlers with defaultMux in net/http for entities of
-// this type
+// CreateApiObject registers ec so that Router and GenerateOpenAPI can
+// serve it: the path it's reachable at is derived from ec.GetRestName()
+// and the chain of ec.GetParentCollection(), so registration order
+// doesn't matter as long as a collection's parent is registered too.
 func CreateApiObject(ec EntityCollection) {
-	sHandler, pHandler := entityApiHandlerFactory(ec)
-
-	// apply security authorization
-	sHandler = applySecurity(sHandler)
-	pHandler = applySecurity(pHandler)
-
-	// apply CORS headers
-	sHandler = applyCorsHeaders(sHandler)
-	pHandler = applyCorsHeaders(pHandler)
-
-	entityServeMux.Handle("/"+ec.GetRestName(), pHandler)
-	sPath := "/" + ec.GetRestName() + "/"
-	entityServeMux.Handle(sPath, sHandler)
+	registeredCollections = append(registeredCollections, ec)
 }
 
 // import ("fmt")
@@ -78,15 +66,11 @@ type EntityCollection interface {
 	DelEntity(targetUuid uuid.UUID) error
 }
 
-func SetRequestorAuthFn(raf func(uname, pwd string) (Entity, error)) {
-	getRequestor = raf
-}
-
 type CollFilter struct {
-	Page        *int64
-	Count       *uint64
-	Sort        []SortObj
-	PropFilters []PropFilterObj
+	Page   *int64
+	Count  *uint64
+	Sort   []SortObj
+	Filter FilterExpr
 }
 
 type Order uint
@@ -109,14 +93,21 @@ const (
 	EQ
 	GT
 	GTEQ
+	NEQ
+	IN
+	LIKE
+	BETWEEN
+	NULL
+	NOTNULL
 )
 
-type PropFilterObj struct {
-	Comp      CompType
-	FieldName string
-	Value     string
-}
-
+// Collection is a page of Entities, along with TotalEntities: the full
+// count of entities matching the request's filter, not just the number
+// returned in this page. Cursor-paginated responses (see
+// CursorPaginatedCollection) leave TotalEntities at 0, since a keyset
+// cursor has no cheap way to compute the full matching count; it is not
+// meaningful there the way it is for offset pagination's
+// first/prev/next/last Link header and Content-Range header.
 type Collection struct {
 	TotalEntities uint
 	Entities      []Entity
@@ -135,26 +126,6 @@ func (cf *CollFilter) popSort(sortString string) {
 	}
 }
 
-func (cf *CollFilter) popFilter(filterQuery url.Values) {
-	for k, va := range filterQuery {
-		for _, v := range va {
-			if v[:3] == "lt." {
-				cf.PropFilters = append(cf.PropFilters, PropFilterObj{Comp: LT, FieldName: k, Value: v[:3]})
-			} else if v[:5] == "lteq." {
-				cf.PropFilters = append(cf.PropFilters, PropFilterObj{Comp: LTEQ, FieldName: k, Value: v[:5]})
-			} else if v[:3] == "eq." {
-				cf.PropFilters = append(cf.PropFilters, PropFilterObj{Comp: EQ, FieldName: k, Value: v[:3]})
-			} else if v[:3] == "gt." {
-				cf.PropFilters = append(cf.PropFilters, PropFilterObj{Comp: GT, FieldName: k, Value: v[:3]})
-			} else if v[:5] == "gteq." {
-				cf.PropFilters = append(cf.PropFilters, PropFilterObj{Comp: GTEQ, FieldName: k, Value: v[:5]})
-			} else {
-				log.Println("WARNING: failed to parse filter query parameter, '" + k + "'")
-			}
-		}
-	}
-}
-
 func (cf *CollFilter) pop(r *http.Request) error {
 	q := r.URL.Query()
 	pageS, ok := q["page"]
@@ -183,7 +154,12 @@ func (cf *CollFilter) pop(r *http.Request) error {
 	}
 	delete(q, "sort")
 
-	cf.popFilter(q)
+	filter, err := parseFilters(q)
+	if err != nil {
+		log.Printf("WARNING: failed to parse filter query parameters: %v", err)
+		return err
+	}
+	cf.Filter = filter
 	return nil
 }
 
@@ -194,17 +170,20 @@ func (cf *CollFilter) pop(r *http.Request) error {
 // and deletion of an entity
 func getSingularHandler(ec EntityCollection) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		pathComponents := strings.Split(r.URL.Path, "/")[1:]
-		entityUuid, err := uuid.FromString(pathComponents[len(pathComponents)-1])
+		entityUuid := entityUuidFromRequest(r)
 
-		if err != nil {
-			log.Printf("error parsing UUID (%s): %v", pathComponents[len(pathComponents)-1], err)
-			http.Error(w, "error parsing  UUID", http.StatusBadRequest)
-			return
-		}
+		authz, hasAuthz := ec.(AuthorizedEntityCollection)
+		requestor := getRequestorFromRequest(r)
 
 		switch r.Method {
 		case http.MethodPut:
+			if hasAuthz {
+				if err := authz.AuthorizeEdit(requestor, entityUuid); err != nil {
+					writeAuthzError(w, err.Error(), nil)
+					return
+				}
+			}
+
 			b, err := ioutil.ReadAll(r.Body)
 			if err != nil {
 				log.Printf("error parsing request body: %v", err)
@@ -220,13 +199,27 @@ func getSingularHandler(ec EntityCollection) http.Handler {
 
 			return
 		case http.MethodDelete:
-			err = ec.DelEntity(entityUuid)
+			if hasAuthz {
+				if err := authz.AuthorizeDelete(requestor, entityUuid); err != nil {
+					writeAuthzError(w, err.Error(), nil)
+					return
+				}
+			}
+
+			err := ec.DelEntity(entityUuid)
 			if err != nil {
 				log.Printf("error deleting entity: %v", err)
 				http.Error(w, "error deleting entity", http.StatusInternalServerError)
 				return
 			}
 		case http.MethodGet:
+			if hasAuthz {
+				if err := authz.AuthorizeRead(requestor, entityUuid); err != nil {
+					writeAuthzError(w, err.Error(), nil)
+					return
+				}
+			}
+
 			var entityJson []byte
 			entity, err := ec.GetEntity(entityUuid)
 			if err != nil {
@@ -248,75 +241,47 @@ func getSingularHandler(ec EntityCollection) http.Handler {
 	})
 }
 
-type pathComponentError string
-
-func (p pathComponentError) Error() string {
-	return fmt.Sprintf("collection entity URL (%s) should have an even number of components (entity name and UUID for each parent entity and name for entity)", string(p))
-}
-
-type parseUUIDError struct {
-	pathComponent string
-	parseError    error
-}
-
-func (p parseUUIDError) Error() string {
-	return fmt.Sprintf("error decoding UUID of path component (%s) : %s", p.pathComponent, p.parseError)
-}
-
-// getPathComponentUuids(path string) takes a path that should be composed
-// of one or more repeats of '/<component-name>/<uuid>'
-// and then a final '/<component-name>'. Function processes
-// this into a map mapping from '<component-name>'s to UUIDs which is returned.
-// Can return an empty map and 'pathComponentError' if path is not of right
-// form, or an empty map and 'parseUUIDError' if any one of the '<uuid>'s is not
-// in a form suitable for parsing into a UUID
-func getPathComponentUuids(path string) (map[string]uuid.UUID, error) {
-	pathComponents := strings.Split(path, "/")[1:]
-
-	if len(pathComponents)%2 != 1 {
-		return map[string]uuid.UUID{}, pathComponentError(path)
-	}
-
-	var err error
-	parentEntityUuids := make(map[string]uuid.UUID)
-	for i := 0; i < len(pathComponents)-1; i += 2 {
-		parentEntityUuids[pathComponents[i]], err = uuid.FromString(pathComponents[i+1])
-
-		if err != nil {
-			return map[string]uuid.UUID{}, parseUUIDError{pathComponent: pathComponents[i], parseError: err}
-		}
-	}
-	return parentEntityUuids, nil
-}
-
 // getPluralHandler(ec EntityCollection) returns a http.Handler for
 // dealing with requests involving the whole EntityCollection 'ec'.
 // This includes creation of entity in collection, and retrieval of
 // whole collection
 func getPluralHandler(ec EntityCollection) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parentEntityUuids, err := getPathComponentUuids(r.URL.Path)
-		if err != nil {
-			log.Println(err)
-			switch err := err.(type) {
-			default:
-				http.Error(w, "unexpected error", http.StatusInternalServerError)
-			case pathComponentError:
-				http.Error(w, "invalid collection path", http.StatusNotFound)
-			case parseUUIDError:
-				http.Error(w, fmt.Sprintf("invalid UUID for component: %s", err.pathComponent), http.StatusNotFound)
-			}
-			return
-		}
+		parentEntityUuids := parentEntityUuidsFromRequest(r)
+
+		authz, hasAuthz := ec.(AuthorizedEntityCollection)
+		requestor := getRequestorFromRequest(r)
 
 		switch r.Method {
 		case http.MethodGet:
+			if hasAuthz {
+				if err := authz.AuthorizeList(requestor, parentEntityUuids); err != nil {
+					writeAuthzError(w, err.Error(), nil)
+					return
+				}
+			}
+
+			if cursorEc, ok := ec.(CursorPaginatedCollection); ok {
+				if _, hasCursor := r.URL.Query()["cursor"]; hasCursor {
+					serveCursorCollection(w, r, cursorEc, parentEntityUuids)
+					return
+				}
+			}
+
 			var entityJson []byte
 			var cf CollFilter
-			err = cf.pop(r)
+			err := cf.pop(r)
 			if err != nil {
 				log.Printf("error retrieving collection, parsing collection filters: %v", err)
 				http.Error(w, "error parsing collection filters", http.StatusBadRequest)
+				return
+			}
+
+			if fsp, ok := ec.(FilterSchemaProvider); ok {
+				if err := cf.Validate(fsp.FilterableFields()); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
 			}
 
 			c, err := ec.GetCollection(parentEntityUuids, cf)
@@ -326,6 +291,17 @@ func getPluralHandler(ec EntityCollection) http.Handler {
 				return
 			}
 
+			if cf.Count != nil {
+				page := int64(0)
+				if cf.Page != nil {
+					page = *cf.Page
+				}
+				if link := buildLinkHeader(r, page, *cf.Count, c.TotalEntities); link != "" {
+					w.Header().Set("Link", link)
+				}
+				w.Header().Set("Content-Range", contentRange(page, *cf.Count, c.TotalEntities, len(c.Entities)))
+			}
+
 			entityJson, err = json.Marshal(c)
 			if err != nil {
 				log.Printf("error retrieving collection (%+v): %v", c, err)
@@ -337,15 +313,22 @@ func getPluralHandler(ec EntityCollection) http.Handler {
 			return
 
 		case http.MethodPost:
+			if hasAuthz {
+				if err := authz.AuthorizeCreate(requestor, parentEntityUuids); err != nil {
+					writeAuthzError(w, err.Error(), nil)
+					return
+				}
+			}
+
 			b, err := ioutil.ReadAll(r.Body)
 			if err != nil {
 				log.Printf("error reading request body: %v", err)
 				http.Error(w, "error reading request body", http.StatusInternalServerError)
 				return
 			}
-			entityPath, err := ec.CreateEntity(getRequestorFromRequest(r), parentEntityUuids, b)
+			entityPath, err := ec.CreateEntity(requestor, parentEntityUuids, b)
 			if err != nil {
-				log.Println("error creating entity: %v", err)
+				log.Printf("error creating entity: %v", err)
 				http.Error(w, "error creating entity", http.StatusBadRequest)
 				return
 			}
@@ -373,60 +356,6 @@ func entityApiHandlerFactory(ec EntityCollection) (http.Handler, http.Handler) {
 	return singularHandler, pluralHandler
 }
 
-// TODO set the Access-Control-Allow-Origin header to a value that can
-// be specified in main
-func applySecurity(handler http.Handler) http.Handler {
-	securityHandler := func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			handler.ServeHTTP(w, r)
-			return
-		}
-
-		var uname, pword, ok = r.BasicAuth()
-		if !ok {
-			w.Header().Add("Access-Control-Allow-Origin", "http://localhost:8090")
-			w.Header().Add("WWW-Authenticate", "Basic realm=\"a\"")
-			http.Error(w, "", http.StatusUnauthorized)
-			return
-		}
-
-		requestor, err := getRequestor(uname, pword)
-		if err != nil {
-			http.Error(w, "incorrect uname/pword", http.StatusForbidden)
-			return
-		}
-		ctx := context.WithValue(r.Context(), requestorKey, requestor)
-		handler.ServeHTTP(w, r.WithContext(ctx))
-	}
-
-	return http.HandlerFunc(securityHandler)
-}
-
-func applyCorsHeaders(handler http.Handler) http.Handler {
-	corsHandler := func(w http.ResponseWriter, r *http.Request) {
-
-		if r.Method == http.MethodOptions {
-			w.Header().Add("Access-Control-Allow-Origin", "http://localhost:8090")
-			w.Header().Add("Access-Control-Allow-Headers", "Authorization")
-			// TODO allow specification of the allowed methods
-			w.Header().Add("Access-Control-Allow-Methods", "GET, PUT, POST, DELETE")
-			return
-		} else if r.Method == http.MethodGet || r.Method == http.MethodPut || r.Method == http.MethodPost || r.Method == http.MethodDelete {
-			w.Header().Add("Access-Control-Allow-Origin", "http://localhost:8090")
-			w.Header().Add("Access-Control-Expose-Headers", "Location")
-			handler.ServeHTTP(w, r)
-		}
-	}
-
-	return http.HandlerFunc(corsHandler)
-}
-
-// ServeMux for storing direct paths to entities
-// the `rootApiHandler` will process the
-// url it receives and look for entities to call
-// the handler of
-var entityServeMux http.ServeMux
-
 type key int
 
 const requestorKey key = 0
@@ -437,44 +366,8 @@ func getRequestorFromRequest(r *http.Request) Entity {
 	return r.Context().Value(requestorKey)
 }
 
-// TODO don't expose this, rather get the api root and
-// set this up internally
-// handles all requests to the api root, processes the requested URL
-// to see what entity the request deals with and gets that handler to
-// serve the request
-var RootApiHandler http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
-	pathBu := r.URL.Path
-
-	// split url into components
-	pathComponents := strings.Split(r.URL.Path, "/")
-
-	// first hypothesis: request for collection of entities, where
-	// final component of path is entity name
-	entityName := pathComponents[len(pathComponents)-1]
-	// see if there is a handler for this
-	r.URL.Path = "/" + entityName
-	h, pattern := entityServeMux.Handler(r)
-	if pattern != "" {
-		r.URL.Path = pathBu
-		h.ServeHTTP(w, r)
-		return
-	}
-
-	// second hypothesis: request for single entity, where
-	// final component is entity id and penultimate component
-	// is entity name
-	entityName = pathComponents[len(pathComponents)-2]
-	r.URL.Path = "/" + entityName + "/"
-	h, pattern = entityServeMux.Handler(r)
-	if pattern != "" {
-		r.URL.Path = pathBu
-		h.ServeHTTP(w, r)
-		return
-	}
-
-	// no patterns found. Can just call ServeHTTP
-	// on handler returned by failed search, since
-	// it will be a not found handler
-	r.URL.Path = pathBu
-	h.ServeHTTP(w, r)
+// withRequestor returns a copy of ctx carrying requestor, the Entity
+// that an Authenticator determined made the request.
+func withRequestor(ctx context.Context, requestor Entity) context.Context {
+	return context.WithValue(ctx, requestorKey, requestor)
 }
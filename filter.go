@@ -0,0 +1,238 @@
+package entitycoll
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// FilterExpr is the typed AST produced by parseFilters from a request's
+// filter query parameters. It is implemented by FilterNode (a single
+// comparison) and FilterGroup (a boolean composition of FilterExprs).
+// EntityCollection implementations receive this AST via CollFilter.Filter
+// instead of the old flat []PropFilterObj, so they can translate
+// arbitrarily nested filters into whatever query they run against.
+type FilterExpr interface {
+	isFilterExpr()
+}
+
+// BoolOp is the boolean composition applied to a FilterGroup's Nodes.
+type BoolOp uint
+
+const (
+	And BoolOp = iota
+	Or
+)
+
+// FilterNode is a single comparison against one field, e.g. the
+// "age=gt.30" query parameter becomes FilterNode{Comp: GT, FieldName:
+// "age", Value: "30"}. Values holds the operands for comparators that
+// take more than one (IN, BETWEEN); Value is used for the rest.
+type FilterNode struct {
+	Comp      CompType
+	FieldName string
+	Value     string
+	Values    []string
+}
+
+func (FilterNode) isFilterExpr() {}
+
+// FilterGroup composes Nodes with Bool (And/Or). The group built by
+// parseFilters for a request's top-level query parameters uses And;
+// an "or=(...)" parameter contributes a nested Or group.
+type FilterGroup struct {
+	Bool  BoolOp
+	Nodes []FilterExpr
+}
+
+func (FilterGroup) isFilterExpr() {}
+
+// FilterSchemaProvider is implemented by an EntityCollection that wants
+// its filters validated against a fixed set of filterable fields.
+// getPluralHandler calls Validate with this set before invoking
+// GetCollection, rejecting filters on unknown fields with 400.
+type FilterSchemaProvider interface {
+	FilterableFields() map[string]bool
+}
+
+// unknownFilterFieldError is returned by CollFilter.Validate.
+type unknownFilterFieldError string
+
+func (e unknownFilterFieldError) Error() string {
+	return fmt.Sprintf("filter references unknown or non-filterable field %q", string(e))
+}
+
+// Validate walks cf.Filter and cf.Sort and returns an
+// unknownFilterFieldError for the first field referenced that isn't
+// present (with a true value) in filterable.
+func (cf *CollFilter) Validate(filterable map[string]bool) error {
+	if cf.Filter != nil {
+		if err := validateExpr(cf.Filter, filterable); err != nil {
+			return err
+		}
+	}
+	for _, s := range cf.Sort {
+		if !filterable[s.FieldName] {
+			return unknownFilterFieldError(s.FieldName)
+		}
+	}
+	return nil
+}
+
+func validateExpr(expr FilterExpr, filterable map[string]bool) error {
+	switch e := expr.(type) {
+	case FilterNode:
+		if !filterable[e.FieldName] {
+			return unknownFilterFieldError(e.FieldName)
+		}
+	case FilterGroup:
+		for _, n := range e.Nodes {
+			if err := validateExpr(n, filterable); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseFilters builds the FilterExpr AST for a request's filter query
+// parameters. Every parameter other than the reserved "or" becomes a
+// FilterNode (or several, for repeated keys/values); all of those are
+// ANDed together in the returned FilterGroup. A single "or" parameter,
+// of the form "or=(field.op.value,field.op.value,...)", contributes a
+// nested Or group alongside them.
+func parseFilters(filterQuery url.Values) (FilterExpr, error) {
+	group := FilterGroup{Bool: And}
+
+	if orS, ok := filterQuery["or"]; ok {
+		for _, raw := range orS {
+			orGroup, err := parseOrGroup(raw)
+			if err != nil {
+				return nil, err
+			}
+			group.Nodes = append(group.Nodes, orGroup)
+		}
+		delete(filterQuery, "or")
+	}
+
+	for k, va := range filterQuery {
+		for _, v := range va {
+			node, err := parseComparison(k, v)
+			if err != nil {
+				return nil, err
+			}
+			group.Nodes = append(group.Nodes, node)
+		}
+	}
+
+	return group, nil
+}
+
+// parseOrGroup parses the value of an "or" query parameter:
+// "(field.op.value,field.op.value,...)".
+func parseOrGroup(raw string) (FilterGroup, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "(") || !strings.HasSuffix(raw, ")") {
+		return FilterGroup{}, fmt.Errorf("malformed or=(...) group: %q", raw)
+	}
+	raw = raw[1 : len(raw)-1]
+
+	group := FilterGroup{Bool: Or}
+	for _, clause := range splitTopLevel(raw) {
+		field, rest, ok := strings.Cut(clause, ".")
+		if !ok {
+			return FilterGroup{}, fmt.Errorf("malformed or=(...) clause: %q", clause)
+		}
+		node, err := parseComparison(field, rest)
+		if err != nil {
+			return FilterGroup{}, err
+		}
+		group.Nodes = append(group.Nodes, node)
+	}
+	return group, nil
+}
+
+// parseComparison parses a single "op.value"-shaped query parameter
+// value (or the bare "null"/"notnull" tokens) into a FilterNode for
+// fieldName. Supported operators: eq, neq, lt, lteq, gt, gteq,
+// in.(a,b,c), like.pattern, between.(x,y), null, notnull.
+func parseComparison(fieldName, raw string) (FilterNode, error) {
+	switch {
+	case raw == "null":
+		return FilterNode{Comp: NULL, FieldName: fieldName}, nil
+	case raw == "notnull":
+		return FilterNode{Comp: NOTNULL, FieldName: fieldName}, nil
+	}
+
+	op, value, ok := strings.Cut(raw, ".")
+	if !ok {
+		return FilterNode{}, fmt.Errorf("malformed filter value for %q: %q", fieldName, raw)
+	}
+
+	switch op {
+	case "eq":
+		return FilterNode{Comp: EQ, FieldName: fieldName, Value: value}, nil
+	case "neq":
+		return FilterNode{Comp: NEQ, FieldName: fieldName, Value: value}, nil
+	case "lt":
+		return FilterNode{Comp: LT, FieldName: fieldName, Value: value}, nil
+	case "lteq":
+		return FilterNode{Comp: LTEQ, FieldName: fieldName, Value: value}, nil
+	case "gt":
+		return FilterNode{Comp: GT, FieldName: fieldName, Value: value}, nil
+	case "gteq":
+		return FilterNode{Comp: GTEQ, FieldName: fieldName, Value: value}, nil
+	case "like":
+		return FilterNode{Comp: LIKE, FieldName: fieldName, Value: value}, nil
+	case "in":
+		values, err := parseParenList(value)
+		if err != nil {
+			return FilterNode{}, err
+		}
+		return FilterNode{Comp: IN, FieldName: fieldName, Values: values}, nil
+	case "between":
+		values, err := parseParenList(value)
+		if err != nil {
+			return FilterNode{}, err
+		}
+		if len(values) != 2 {
+			return FilterNode{}, fmt.Errorf("between filter on %q needs exactly 2 values, got %d", fieldName, len(values))
+		}
+		return FilterNode{Comp: BETWEEN, FieldName: fieldName, Values: values}, nil
+	default:
+		return FilterNode{}, fmt.Errorf("unknown filter operator %q for field %q", op, fieldName)
+	}
+}
+
+// parseParenList parses a "(a,b,c)"-shaped value into its comma
+// separated elements.
+func parseParenList(raw string) ([]string, error) {
+	if !strings.HasPrefix(raw, "(") || !strings.HasSuffix(raw, ")") {
+		return nil, fmt.Errorf("malformed list value: %q", raw)
+	}
+	return splitTopLevel(raw[1 : len(raw)-1]), nil
+}
+
+// splitTopLevel splits s on commas that aren't nested inside
+// parentheses, since a group's clauses may themselves contain
+// parenthesized value lists (e.g. or=(age.in.(1,2),name.eq.bob)).
+func splitTopLevel(s string) []string {
+	var out []string
+	depth := 0
+	start := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	out = append(out, s[start:])
+	return out
+}
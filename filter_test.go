@@ -0,0 +1,31 @@
+package entitycoll
+
+import "testing"
+
+func TestCollFilterValidateRejectsUnknownFilterField(t *testing.T) {
+	cf := CollFilter{Filter: FilterNode{Comp: EQ, FieldName: "secret", Value: "1"}}
+	if err := cf.Validate(map[string]bool{"age": true}); err == nil {
+		t.Error("Validate: expected error for unfilterable field, got nil")
+	}
+}
+
+// TestCollFilterValidateRejectsUnknownSortField guards against the
+// field name in a sort parameter flowing unchecked into an ORDER BY
+// clause (sqlstore's orderByClause quotes it but never validates it
+// against the caller's schema).
+func TestCollFilterValidateRejectsUnknownSortField(t *testing.T) {
+	cf := CollFilter{Sort: []SortObj{{SortOrder: ASC, FieldName: `age" ; DROP TABLE users; --`}}}
+	if err := cf.Validate(map[string]bool{"age": true}); err == nil {
+		t.Error("Validate: expected error for unfilterable sort field, got nil")
+	}
+}
+
+func TestCollFilterValidateAcceptsKnownFields(t *testing.T) {
+	cf := CollFilter{
+		Filter: FilterNode{Comp: EQ, FieldName: "age", Value: "30"},
+		Sort:   []SortObj{{SortOrder: DESC, FieldName: "name"}},
+	}
+	if err := cf.Validate(map[string]bool{"age": true, "name": true}); err != nil {
+		t.Errorf("Validate: unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+package entitycoll
+
+import (
+	"encoding"
+	"net/http"
+	"reflect"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/satori/go.uuid"
+)
+
+// textMarshalerType is used by schemaForType to recognize types that
+// encoding/json renders as a string via MarshalText/MarshalJSON rather
+// than by their underlying reflect.Kind -- uuid.UUID being the
+// prototypical example, since it's [16]byte under the hood but always
+// serializes as its canonical string form.
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// registeredCollections holds every EntityCollection that has been
+// wired up via CreateApiObject, in registration order, so that
+// GenerateOpenAPI can walk the whole REST surface without requiring
+// callers to pass the list in again.
+var registeredCollections []EntityCollection
+
+// SchemaExampleProvider is implemented by an EntityCollection that
+// wants its entity JSON schema derived by reflection. EntitySchemaExample
+// should return a zero-value (or representative) instance of the
+// concrete type returned by GetEntity; only its type is inspected,
+// the value itself is never serialized.
+type SchemaExampleProvider interface {
+	EntitySchemaExample() Entity
+}
+
+// collectionPath builds the full REST path of ec, walking
+// GetParentCollection to prefix each ancestor's name and a {uuid}
+// placeholder for its identifier, mirroring the path shape Router
+// resolves a request against.
+func collectionPath(ec EntityCollection) string {
+	if parent := ec.GetParentCollection(); parent != nil {
+		return collectionPath(parent) + "/{" + parent.GetRestName() + "Uuid}/" + ec.GetRestName()
+	}
+	return "/" + ec.GetRestName()
+}
+
+// entitySchemaRef derives a JSON schema for the concrete type returned
+// by ec.GetEntity, when ec implements SchemaExampleProvider. Collections
+// that don't are given a generic, untyped "object" schema rather than
+// an error, since their entities can still be created, read and deleted
+// through the API even without a derived schema.
+func entitySchemaRef(ec EntityCollection) *openapi3.SchemaRef {
+	sep, ok := ec.(SchemaExampleProvider)
+	if !ok {
+		return openapi3.NewSchemaRef("", openapi3.NewObjectSchema())
+	}
+	return openapi3.NewSchemaRef("", schemaForType(reflect.TypeOf(sep.EntitySchemaExample())))
+}
+
+// schemaForType converts a Go type into an *openapi3.Schema, honoring
+// json tags, treating pointers as optional (nullable, non-required)
+// fields, and rendering Order/CompType as enums of their known
+// constant values.
+func schemaForType(t reflect.Type) *openapi3.Schema {
+	if t == nil {
+		return openapi3.NewSchema()
+	}
+
+	if t.Kind() == reflect.Ptr {
+		var schema *openapi3.Schema
+		if t.Implements(textMarshalerType) && !t.Elem().Implements(textMarshalerType) {
+			// MarshalText has a pointer receiver, so only the pointer
+			// renders as a string
+			schema = openapi3.NewStringSchema()
+		} else {
+			schema = schemaForType(t.Elem())
+		}
+		schema.Nullable = true
+		return schema
+	}
+
+	switch t {
+	case reflect.TypeOf(Order(0)):
+		schema := openapi3.NewIntegerSchema()
+		schema.Enum = []interface{}{ASC, DESC}
+		return schema
+	case reflect.TypeOf(CompType(0)):
+		schema := openapi3.NewIntegerSchema()
+		schema.Enum = []interface{}{LT, LTEQ, EQ, GT, GTEQ, NEQ, IN, LIKE, BETWEEN, NULL, NOTNULL}
+		return schema
+	case reflect.TypeOf(uuid.UUID{}):
+		return openapi3.NewUUIDSchema()
+	}
+
+	if t.Implements(textMarshalerType) {
+		return openapi3.NewStringSchema()
+	}
+
+	var schema *openapi3.Schema
+	switch t.Kind() {
+	case reflect.String:
+		schema = openapi3.NewStringSchema()
+	case reflect.Bool:
+		schema = openapi3.NewBoolSchema()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema = openapi3.NewIntegerSchema()
+	case reflect.Float32, reflect.Float64:
+		schema = openapi3.NewFloat64Schema()
+	case reflect.Slice, reflect.Array:
+		schema = openapi3.NewArraySchema()
+		schema.Items = openapi3.NewSchemaRef("", schemaForType(t.Elem()))
+	case reflect.Struct:
+		schema = openapi3.NewObjectSchema()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				// unexported field, not part of the JSON representation
+				continue
+			}
+			name, omit := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+			schema.Properties[name] = openapi3.NewSchemaRef("", schemaForType(field.Type))
+			if field.Type.Kind() != reflect.Ptr && !omit {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	default:
+		schema = openapi3.NewSchema()
+	}
+
+	return schema
+}
+
+// jsonFieldName resolves the JSON name encoding/json would use for field,
+// along with whether it carries the "omitempty" option.
+func jsonFieldName(field reflect.StructField) (name string, omitempty bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+	segs := splitComma(tag)
+	name = segs[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range segs[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty
+}
+
+func splitComma(s string) []string {
+	var out []string
+	start := 0
+	for i, r := range s {
+		if r == ',' {
+			out = append(out, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, s[start:])
+}
+
+// GenerateOpenAPI builds an OpenAPI 3 document describing every
+// EntityCollection registered via CreateApiObject: parent path segments,
+// singular GET/PUT/DELETE, plural GET/POST, the CollFilter query
+// parameters, 201 Location responses on create, the 401 produced by
+// applySecurity and the 403 produced by authorization checks. The returned *openapi3.T is a regular kin-openapi
+// document, so it can be fed straight into oapi-codegen or validated
+// with openapi3.Loader/openapi3filter like any other.
+func GenerateOpenAPI() (*openapi3.T, error) {
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info:    &openapi3.Info{Title: "entitycoll API", Version: "1.0.0"},
+		Paths:   openapi3.Paths{},
+		Components: &openapi3.Components{
+			Schemas: openapi3.Schemas{},
+		},
+	}
+
+	for _, ec := range registeredCollections {
+		name := ec.GetRestName()
+		schemaRef := entitySchemaRef(ec)
+		doc.Components.Schemas[name] = schemaRef
+
+		pluralPath := collectionPath(ec)
+		singularPath := pluralPath + "/{uuid}"
+
+		collectionSchema := openapi3.NewObjectSchema()
+		collectionSchema.Properties["TotalEntities"] = openapi3.NewSchemaRef("", openapi3.NewIntegerSchema())
+		entitiesSchema := openapi3.NewArraySchema()
+		entitiesSchema.Items = schemaRef
+		collectionSchema.Properties["Entities"] = openapi3.NewSchemaRef("", entitiesSchema)
+
+		doc.Paths[pluralPath] = &openapi3.PathItem{
+			Get: &openapi3.Operation{
+				Summary:    "list " + name,
+				Parameters: collFilterParameters(),
+				Responses: openapi3.Responses{
+					"200": &openapi3.ResponseRef{Value: openapi3.NewResponse().
+						WithDescription("collection of " + name).
+						WithContent(openapi3.NewContentWithJSONSchema(collectionSchema))},
+					"401": unauthorizedResponse(),
+					"403": forbiddenResponse(),
+				},
+			},
+			Post: &openapi3.Operation{
+				Summary: "create " + name,
+				Responses: openapi3.Responses{
+					"201": &openapi3.ResponseRef{Value: &openapi3.Response{
+						Description: strPtr("created"),
+						Headers: openapi3.Headers{
+							"Location": &openapi3.HeaderRef{Value: &openapi3.Header{
+								Parameter: openapi3.Parameter{Schema: openapi3.NewSchemaRef("", openapi3.NewStringSchema())},
+							}},
+						},
+					}},
+					"400": &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("invalid entity body")},
+					"401": unauthorizedResponse(),
+					"403": forbiddenResponse(),
+				},
+			},
+			Options: preflightOperation(),
+		}
+
+		doc.Paths[singularPath] = &openapi3.PathItem{
+			Get: &openapi3.Operation{
+				Summary: "get " + name,
+				Responses: openapi3.Responses{
+					"200": &openapi3.ResponseRef{Value: openapi3.NewResponse().
+						WithDescription(name).
+						WithContent(openapi3.NewContentWithJSONSchemaRef(schemaRef))},
+					"401": unauthorizedResponse(),
+					"403": forbiddenResponse(),
+					"404": &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("not found")},
+				},
+			},
+			Put: &openapi3.Operation{
+				Summary: "edit " + name,
+				Responses: openapi3.Responses{
+					"200": &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("updated")},
+					"401": unauthorizedResponse(),
+					"403": forbiddenResponse(),
+				},
+			},
+			Delete: &openapi3.Operation{
+				Summary: "delete " + name,
+				Responses: openapi3.Responses{
+					"200": &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("deleted")},
+					"401": unauthorizedResponse(),
+					"403": forbiddenResponse(),
+				},
+			},
+			Options: preflightOperation(),
+		}
+	}
+
+	return doc, nil
+}
+
+// collFilterParameters documents the query parameters getPluralHandler's
+// GET case accepts via CollFilter.pop: "page"/"count"/"sort", plus the
+// filter grammar parseFilters understands -- "<field>=eq.v", "neq.v",
+// "lt.v", "lteq.v", "gt.v", "gteq.v", "like.v", "in.(a,b,c)",
+// "between.(a,b)", "null", "notnull", and the grouping parameter
+// "or=(field.op.value,...)".
+func collFilterParameters() openapi3.Parameters {
+	params := []*openapi3.Parameter{
+		openapi3.NewQueryParameter("page").WithSchema(openapi3.NewIntegerSchema()),
+		openapi3.NewQueryParameter("count").WithSchema(openapi3.NewIntegerSchema()),
+		openapi3.NewQueryParameter("sort").
+			WithDescription(`comma separated list of "asc.<field>"/"desc.<field>"`).
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter("<field>").
+			WithDescription(`filter on <field>: one of "eq.v", "neq.v", "lt.v", "lteq.v", ` +
+				`"gt.v", "gteq.v", "like.v", "in.(a,b,c)", "between.(a,b)", "null" or "notnull"`).
+			WithSchema(openapi3.NewStringSchema()),
+		openapi3.NewQueryParameter("or").
+			WithDescription(`"(field.op.value,field.op.value,...)" -- ORs the listed comparisons together`).
+			WithSchema(openapi3.NewStringSchema()),
+	}
+
+	refs := make(openapi3.Parameters, len(params))
+	for i, p := range params {
+		refs[i] = &openapi3.ParameterRef{Value: p}
+	}
+	return refs
+}
+
+func unauthorizedResponse() *openapi3.ResponseRef {
+	return &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("missing or invalid credentials")}
+}
+
+func forbiddenResponse() *openapi3.ResponseRef {
+	return &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("credentials valid but not authorized")}
+}
+
+func preflightOperation() *openapi3.Operation {
+	return &openapi3.Operation{
+		Summary: "CORS preflight",
+		Responses: openapi3.Responses{
+			"204": &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("preflight response")},
+		},
+	}
+}
+
+func strPtr(s string) *string { return &s }
+
+// openAPIHandler serves the result of GenerateOpenAPI as application/json.
+func openAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, err := GenerateOpenAPI()
+		if err != nil {
+			http.Error(w, "error generating OpenAPI document", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		body, err := doc.MarshalJSON()
+		if err != nil {
+			http.Error(w, "error encoding OpenAPI document", http.StatusInternalServerError)
+			return
+		}
+		w.Write(body)
+	})
+}
+
+// MountOpenAPI registers the generated OpenAPI document at /openapi.json
+// on mux, alongside Router() mounted wherever the caller chooses, so
+// clients and codegen tools (e.g. oapi-codegen) can fetch a description
+// of every collection registered so far.
+func MountOpenAPI(mux *http.ServeMux) {
+	mux.Handle("/openapi.json", openAPIHandler())
+}
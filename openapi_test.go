@@ -0,0 +1,60 @@
+package entitycoll
+
+import (
+	"testing"
+
+	"github.com/satori/go.uuid"
+)
+
+type testWidget struct {
+	Uuid uuid.UUID `json:"uuid"`
+	Name string    `json:"name"`
+}
+
+type testWidgetCollection struct{}
+
+func (testWidgetCollection) GetRestName() string                   { return "widgets" }
+func (testWidgetCollection) GetParentCollection() EntityCollection { return nil }
+func (testWidgetCollection) CreateEntity(Entity, map[string]uuid.UUID, []byte) (string, error) {
+	return "", nil
+}
+func (testWidgetCollection) GetEntity(uuid.UUID) (Entity, error) { return testWidget{}, nil }
+func (testWidgetCollection) GetCollection(map[string]uuid.UUID, CollFilter) (Collection, error) {
+	return Collection{}, nil
+}
+func (testWidgetCollection) EditEntity(uuid.UUID, []byte) error { return nil }
+func (testWidgetCollection) DelEntity(uuid.UUID) error          { return nil }
+func (testWidgetCollection) EntitySchemaExample() Entity        { return testWidget{} }
+
+func TestGenerateOpenAPIDescribesRegisteredCollection(t *testing.T) {
+	saved := registeredCollections
+	defer func() { registeredCollections = saved }()
+	registeredCollections = []EntityCollection{testWidgetCollection{}}
+
+	doc, err := GenerateOpenAPI()
+	if err != nil {
+		t.Fatalf("GenerateOpenAPI: unexpected error: %v", err)
+	}
+
+	if doc.Paths["/widgets"] == nil || doc.Paths["/widgets"].Get == nil || doc.Paths["/widgets"].Post == nil {
+		t.Fatalf("GenerateOpenAPI: expected GET/POST on /widgets, got %+v", doc.Paths["/widgets"])
+	}
+	if doc.Paths["/widgets/{uuid}"] == nil || doc.Paths["/widgets/{uuid}"].Get == nil {
+		t.Fatalf("GenerateOpenAPI: expected GET on /widgets/{uuid}, got %+v", doc.Paths["/widgets/{uuid}"])
+	}
+
+	schemaRef, ok := doc.Components.Schemas["widgets"]
+	if !ok || schemaRef.Value.Type != "object" {
+		t.Fatalf("GenerateOpenAPI: expected an object schema for widgets, got %+v", schemaRef)
+	}
+	if _, ok := schemaRef.Value.Properties["name"]; !ok {
+		t.Errorf("GenerateOpenAPI: expected widgets schema to have a 'name' property, got %+v", schemaRef.Value.Properties)
+	}
+	if uuidProp, ok := schemaRef.Value.Properties["uuid"]; !ok || uuidProp.Value.Type != "string" || uuidProp.Value.Format != "uuid" {
+		t.Errorf("GenerateOpenAPI: expected widgets schema uuid property to be a string with format uuid, got %+v", uuidProp)
+	}
+
+	if _, err := doc.MarshalJSON(); err != nil {
+		t.Errorf("GenerateOpenAPI: document failed to marshal: %v", err)
+	}
+}
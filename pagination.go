@@ -0,0 +1,127 @@
+package entitycoll
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/satori/go.uuid"
+)
+
+// CursorPaginatedCollection is an optional sibling to EntityCollection
+// for collections that prefer keyset (cursor) pagination over large
+// tables rather than Page/Count-based offset pagination. When a
+// collection implements it, a plural GET carrying a "cursor" query
+// parameter (count still taken from "count") is served by
+// GetCollectionCursor instead of GetCollection, and the response gets a
+// single rel="next" Link header built from the returned cursor rather
+// than the first/prev/next/last set offset pagination produces.
+type CursorPaginatedCollection interface {
+	EntityCollection
+
+	// GetCollectionCursor returns up to count entities after cursor
+	// (the empty string means "from the start"), plus the cursor to
+	// pass to retrieve the next page, which is empty when there are no
+	// more entities.
+	GetCollectionCursor(parentEntityUuids map[string]uuid.UUID, cursor string, count uint64) (entities []Entity, nextCursor string, err error)
+}
+
+// buildLinkHeader returns an RFC 5988 Link header value with
+// rel="first"/"prev"/"next"/"last" entries computed from page, count
+// and total, reusing r's URL and query string except for the "page"
+// parameter. It returns "" when count is 0, since pages aren't
+// meaningful without a page size.
+func buildLinkHeader(r *http.Request, page int64, count uint64, total uint) string {
+	if count == 0 {
+		return ""
+	}
+
+	lastPage := int64(0)
+	if total > 0 {
+		lastPage = (int64(total) - 1) / int64(count)
+	}
+
+	linkFor := func(rel string, p int64) string {
+		q := r.URL.Query()
+		q.Set("page", strconv.FormatInt(p, 10))
+		u := *r.URL
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+	}
+
+	links := []string{linkFor("first", 0)}
+	if page > 0 {
+		links = append(links, linkFor("prev", page-1))
+	}
+	if page < lastPage {
+		links = append(links, linkFor("next", page+1))
+	}
+	links = append(links, linkFor("last", lastPage))
+
+	return strings.Join(links, ", ")
+}
+
+// buildCursorLinkHeader returns a Link header with a single rel="next"
+// entry built from nextCursor, or "" if there is no next page.
+func buildCursorLinkHeader(r *http.Request, nextCursor string) string {
+	if nextCursor == "" {
+		return ""
+	}
+	q := r.URL.Query()
+	q.Set("cursor", nextCursor)
+	u := *r.URL
+	u.RawQuery = q.Encode()
+	return fmt.Sprintf(`<%s>; rel="next"`, u.String())
+}
+
+// serveCursorCollection answers a plural GET carrying a "cursor" query
+// parameter via cursorEc.GetCollectionCursor, setting a rel="next" Link
+// header from the cursor it returns instead of the offset-pagination
+// headers buildLinkHeader/contentRange produce.
+func serveCursorCollection(w http.ResponseWriter, r *http.Request, cursorEc CursorPaginatedCollection, parentEntityUuids map[string]uuid.UUID) {
+	cursor := r.URL.Query().Get("cursor")
+
+	count, err := strconv.ParseUint(r.URL.Query().Get("count"), 10, 64)
+	if err != nil {
+		http.Error(w, "error parsing 'count' query parameter", http.StatusBadRequest)
+		return
+	}
+
+	entities, nextCursor, err := cursorEc.GetCollectionCursor(parentEntityUuids, cursor, count)
+	if err != nil {
+		log.Printf("error retrieving collection, getting cursor page: %v", err)
+		http.Error(w, "error retrieving collection", http.StatusNotFound)
+		return
+	}
+
+	if link := buildCursorLinkHeader(r, nextCursor); link != "" {
+		w.Header().Set("Link", link)
+	}
+
+	// TotalEntities is left at 0 rather than len(entities): that would
+	// be the page size, not the full matching count, and cursor
+	// pagination has no cheap way to compute the latter (see
+	// Collection's doc comment).
+	entityJson, err := json.Marshal(Collection{Entities: entities})
+	if err != nil {
+		log.Printf("error encoding cursor collection: %v", err)
+		http.Error(w, "error encoding JSON", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(entityJson))
+}
+
+// contentRange returns a Content-Range header value of the form
+// "items {start}-{end}/{total}" for the page'th page of size count out
+// of total, or "items */{total}" when that page is empty.
+func contentRange(page int64, count uint64, total uint, returned int) string {
+	if returned == 0 {
+		return fmt.Sprintf("items */%d", total)
+	}
+	start := page * int64(count)
+	end := start + int64(returned) - 1
+	return fmt.Sprintf("items %d-%d/%d", start, end, total)
+}
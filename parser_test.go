@@ -0,0 +1,138 @@
+package entitycoll
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseComparison(t *testing.T) {
+	cases := []struct {
+		name    string
+		field   string
+		raw     string
+		want    FilterNode
+		wantErr bool
+	}{
+		{"eq", "age", "eq.30", FilterNode{Comp: EQ, FieldName: "age", Value: "30"}, false},
+		{"neq", "age", "neq.30", FilterNode{Comp: NEQ, FieldName: "age", Value: "30"}, false},
+		{"lt", "age", "lt.30", FilterNode{Comp: LT, FieldName: "age", Value: "30"}, false},
+		{"lteq", "age", "lteq.30", FilterNode{Comp: LTEQ, FieldName: "age", Value: "30"}, false},
+		{"gt", "age", "gt.30", FilterNode{Comp: GT, FieldName: "age", Value: "30"}, false},
+		{"gteq", "age", "gteq.30", FilterNode{Comp: GTEQ, FieldName: "age", Value: "30"}, false},
+		{"like", "name", "like.bob%", FilterNode{Comp: LIKE, FieldName: "name", Value: "bob%"}, false},
+		{"null", "name", "null", FilterNode{Comp: NULL, FieldName: "name"}, false},
+		{"notnull", "name", "notnull", FilterNode{Comp: NOTNULL, FieldName: "name"}, false},
+		{"in", "age", "in.(1,2,3)", FilterNode{Comp: IN, FieldName: "age", Values: []string{"1", "2", "3"}}, false},
+		{"between", "age", "between.(1,10)", FilterNode{Comp: BETWEEN, FieldName: "age", Values: []string{"1", "10"}}, false},
+		{"between wrong count", "age", "between.(1,10,20)", FilterNode{}, true},
+		{"unknown op", "age", "bogus.1", FilterNode{}, true},
+		{"malformed", "age", "noop", FilterNode{}, true},
+		// value itself containing a dot must not get cut short -- op.value
+		// splits on the first "." only, so the rest belongs to value.
+		{"value with dot", "version", "eq.1.2.3", FilterNode{Comp: EQ, FieldName: "version", Value: "1.2.3"}, false},
+		{"like with dot", "email", "like.bob@example.com", FilterNode{Comp: LIKE, FieldName: "email", Value: "bob@example.com"}, false},
+		// a single-element in.() list must not be mistaken for "no values".
+		{"in single value", "age", "in.(1)", FilterNode{Comp: IN, FieldName: "age", Values: []string{"1"}}, false},
+		{"in missing parens", "age", "in.1,2,3", FilterNode{}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseComparison(c.field, c.raw)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parseComparison(%q, %q) = %+v, want error", c.field, c.raw, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseComparison(%q, %q) unexpected error: %v", c.field, c.raw, err)
+			}
+			if got.Comp != c.want.Comp || got.FieldName != c.want.FieldName || got.Value != c.want.Value || len(got.Values) != len(c.want.Values) {
+				t.Errorf("parseComparison(%q, %q) = %+v, want %+v", c.field, c.raw, got, c.want)
+			}
+			for i := range c.want.Values {
+				if got.Values[i] != c.want.Values[i] {
+					t.Errorf("parseComparison(%q, %q).Values[%d] = %q, want %q", c.field, c.raw, i, got.Values[i], c.want.Values[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseFiltersOrGroup(t *testing.T) {
+	q := url.Values{"or": []string{"(age.gt.30,name.eq.bob)"}}
+
+	expr, err := parseFilters(q)
+	if err != nil {
+		t.Fatalf("parseFilters: unexpected error: %v", err)
+	}
+
+	top, ok := expr.(FilterGroup)
+	if !ok || top.Bool != And {
+		t.Fatalf("parseFilters: expected top-level And group, got %+v", expr)
+	}
+	if len(top.Nodes) != 1 {
+		t.Fatalf("parseFilters: expected 1 node (the or-group), got %d", len(top.Nodes))
+	}
+
+	orGroup, ok := top.Nodes[0].(FilterGroup)
+	if !ok || orGroup.Bool != Or {
+		t.Fatalf("parseFilters: expected nested Or group, got %+v", top.Nodes[0])
+	}
+	if len(orGroup.Nodes) != 2 {
+		t.Fatalf("parseFilters: expected 2 nodes in or-group, got %d", len(orGroup.Nodes))
+	}
+}
+
+func TestParseFiltersAndsPlainParameters(t *testing.T) {
+	q := url.Values{"age": []string{"gt.30"}}
+
+	expr, err := parseFilters(q)
+	if err != nil {
+		t.Fatalf("parseFilters: unexpected error: %v", err)
+	}
+
+	top, ok := expr.(FilterGroup)
+	if !ok || top.Bool != And || len(top.Nodes) != 1 {
+		t.Fatalf("parseFilters: expected a single-node And group, got %+v", expr)
+	}
+	node, ok := top.Nodes[0].(FilterNode)
+	if !ok || node.Comp != GT || node.FieldName != "age" || node.Value != "30" {
+		t.Errorf("parseFilters: expected FilterNode{GT, age, 30}, got %+v", top.Nodes[0])
+	}
+}
+
+func TestSplitTopLevelRespectsNestedParens(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"simple", "a,b,c", []string{"a", "b", "c"}},
+		{"nested list", "age.in.(1,2),name.eq.bob", []string{"age.in.(1,2)", "name.eq.bob"}},
+		{"nested list first", "name.eq.bob,age.in.(1,2)", []string{"name.eq.bob", "age.in.(1,2)"}},
+		{"single element", "age.gt.30", []string{"age.gt.30"}},
+		{"empty", "", []string{""}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := splitTopLevel(c.in)
+			if len(got) != len(c.want) {
+				t.Fatalf("splitTopLevel(%q) = %v, want %v", c.in, got, c.want)
+			}
+			for i := range c.want {
+				if got[i] != c.want[i] {
+					t.Errorf("splitTopLevel(%q)[%d] = %q, want %q", c.in, i, got[i], c.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseParenListRejectsMissingParens(t *testing.T) {
+	if _, err := parseParenList("1,2,3"); err == nil {
+		t.Error("parseParenList: expected error for a value missing its parens, got nil")
+	}
+}
@@ -0,0 +1,154 @@
+package entitycoll
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/satori/go.uuid"
+)
+
+// routeNode is one step in the trie Router builds from the registered
+// collections' parent chains (see collectionChain): its children are
+// keyed by REST name, and its handlers (set only on nodes that
+// correspond to a registered EntityCollection) are fully wrapped with
+// that collection's security and CORS policy.
+type routeNode struct {
+	sHandler http.Handler
+	pHandler http.Handler
+	children map[string]*routeNode
+}
+
+// collectionChain returns ec and every ancestor reachable through
+// GetParentCollection, ordered from the root ancestor down to ec.
+func collectionChain(ec EntityCollection) []EntityCollection {
+	var chain []EntityCollection
+	for c := ec; c != nil; c = c.GetParentCollection() {
+		chain = append([]EntityCollection{c}, chain...)
+	}
+	return chain
+}
+
+// buildRouteTrie assembles a trie from registeredCollections, keyed at
+// each depth by REST name, so that a path like
+// /users/{uuid}/projects/{uuid}/tasks can be resolved to the "tasks"
+// collection's handlers in one pass instead of guessing at prefixes.
+func buildRouteTrie() *routeNode {
+	root := &routeNode{children: map[string]*routeNode{}}
+
+	for _, ec := range registeredCollections {
+		node := root
+		for _, c := range collectionChain(ec) {
+			name := c.GetRestName()
+			child, ok := node.children[name]
+			if !ok {
+				child = &routeNode{children: map[string]*routeNode{}}
+				node.children[name] = child
+			}
+			node = child
+		}
+
+		sHandler, pHandler := entityApiHandlerFactory(ec)
+		sHandler = applySecurity(ec, sHandler)
+		pHandler = applySecurity(ec, pHandler)
+		sHandler = applyCorsHeaders(ec, sHandler)
+		pHandler = applyCorsHeaders(ec, pHandler)
+		node.sHandler = sHandler
+		node.pHandler = pHandler
+	}
+
+	return root
+}
+
+// Router returns an http.Handler serving every EntityCollection
+// registered so far via CreateApiObject, resolving nested paths like
+// /users/{uuid}/projects/{uuid}/tasks against a trie keyed on each
+// collection's parent chain rather than by guessing at path prefixes.
+// Parent uuids are extracted while walking the trie and passed to
+// handlers via the request context instead of being re-parsed from the
+// path. The returned Handler can be mounted at any prefix, on any
+// http.Handler-based router.
+func Router() http.Handler {
+	trie := buildRouteTrie()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		components := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(components) == 0 || components[0] == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		node := trie
+		parentUuids := map[string]uuid.UUID{}
+
+		for i := 0; i < len(components); i++ {
+			name := components[i]
+			child, ok := node.children[name]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+
+			if i == len(components)-1 {
+				if child.pHandler == nil {
+					http.NotFound(w, r)
+					return
+				}
+				ctx := withParentUuids(r.Context(), parentUuids)
+				child.pHandler.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			i++
+			targetUuid, err := uuid.FromString(components[i])
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid uuid for %q: %v", name, err), http.StatusNotFound)
+				return
+			}
+
+			if i == len(components)-1 {
+				if child.sHandler == nil {
+					http.NotFound(w, r)
+					return
+				}
+				ctx := withParentUuids(r.Context(), parentUuids)
+				ctx = withEntityUuid(ctx, targetUuid)
+				child.sHandler.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			parentUuids[name] = targetUuid
+			node = child
+		}
+	})
+}
+
+type routeContextKey int
+
+const (
+	parentUuidsKey routeContextKey = iota
+	entityUuidKey
+)
+
+func withParentUuids(ctx context.Context, parentUuids map[string]uuid.UUID) context.Context {
+	return context.WithValue(ctx, parentUuidsKey, parentUuids)
+}
+
+// parentEntityUuidsFromRequest returns the parent-collection uuids
+// Router extracted while resolving r's path.
+func parentEntityUuidsFromRequest(r *http.Request) map[string]uuid.UUID {
+	parentUuids, _ := r.Context().Value(parentUuidsKey).(map[string]uuid.UUID)
+	return parentUuids
+}
+
+func withEntityUuid(ctx context.Context, targetUuid uuid.UUID) context.Context {
+	return context.WithValue(ctx, entityUuidKey, targetUuid)
+}
+
+// entityUuidFromRequest returns the target entity's uuid Router
+// extracted from the final path component of r.
+func entityUuidFromRequest(r *http.Request) uuid.UUID {
+	targetUuid, _ := r.Context().Value(entityUuidKey).(uuid.UUID)
+	return targetUuid
+}
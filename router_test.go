@@ -0,0 +1,151 @@
+package entitycoll
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/satori/go.uuid"
+)
+
+// routerTestAccount and routerTestWidget are a minimal two-level parent
+// chain (accounts -> widgets) used to exercise Router's trie resolution
+// without pulling in sqlstore or auth.
+
+type routerTestAccount struct{}
+
+func (routerTestAccount) GetRestName() string                   { return "accounts" }
+func (routerTestAccount) GetParentCollection() EntityCollection { return nil }
+func (routerTestAccount) CreateEntity(Entity, map[string]uuid.UUID, []byte) (string, error) {
+	return "", nil
+}
+func (routerTestAccount) GetEntity(u uuid.UUID) (Entity, error) {
+	return map[string]string{"kind": "account", "uuid": u.String()}, nil
+}
+func (routerTestAccount) GetCollection(map[string]uuid.UUID, CollFilter) (Collection, error) {
+	return Collection{Entities: []Entity{"accounts-list"}}, nil
+}
+func (routerTestAccount) EditEntity(uuid.UUID, []byte) error { return nil }
+func (routerTestAccount) DelEntity(uuid.UUID) error          { return nil }
+
+type routerTestWidget struct{}
+
+func (routerTestWidget) GetRestName() string { return "widgets" }
+func (routerTestWidget) GetParentCollection() EntityCollection {
+	return routerTestAccount{}
+}
+func (routerTestWidget) CreateEntity(Entity, map[string]uuid.UUID, []byte) (string, error) {
+	return "", nil
+}
+func (routerTestWidget) GetEntity(u uuid.UUID) (Entity, error) {
+	return map[string]string{"kind": "widget", "uuid": u.String()}, nil
+}
+func (routerTestWidget) GetCollection(parentEntityUuids map[string]uuid.UUID, filter CollFilter) (Collection, error) {
+	return Collection{Entities: []Entity{parentEntityUuids["accounts"].String()}}, nil
+}
+func (routerTestWidget) EditEntity(uuid.UUID, []byte) error { return nil }
+func (routerTestWidget) DelEntity(uuid.UUID) error          { return nil }
+
+// withRouterTestCollections registers routerTestAccount and
+// routerTestWidget for the duration of a test, restoring whatever was
+// registered beforehand.
+func withRouterTestCollections(t *testing.T) {
+	t.Helper()
+	saved := registeredCollections
+	t.Cleanup(func() { registeredCollections = saved })
+	registeredCollections = []EntityCollection{routerTestAccount{}, routerTestWidget{}}
+}
+
+func TestRouterResolvesNestedPath(t *testing.T) {
+	withRouterTestCollections(t)
+
+	accountUuid := uuid.NewV4()
+	widgetUuid := uuid.NewV4()
+
+	req := httptest.NewRequest("GET", "/accounts/"+accountUuid.String()+"/widgets/"+widgetUuid.String(), nil)
+	w := httptest.NewRecorder()
+	Router().ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("Router: status = %d, want 200, body = %q", w.Code, w.Body.String())
+	}
+	if want := widgetUuid.String(); !strings.Contains(w.Body.String(), want) {
+		t.Errorf("Router: body = %q, want it to contain widget uuid %q", w.Body.String(), want)
+	}
+	if !strings.Contains(w.Body.String(), "widget") {
+		t.Errorf("Router: body = %q, want the widget's singular handler, not the account's", w.Body.String())
+	}
+}
+
+func TestRouterResolvesNestedPluralPath(t *testing.T) {
+	withRouterTestCollections(t)
+
+	accountUuid := uuid.NewV4()
+
+	req := httptest.NewRequest("GET", "/accounts/"+accountUuid.String()+"/widgets", nil)
+	w := httptest.NewRecorder()
+	Router().ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("Router: status = %d, want 200, body = %q", w.Code, w.Body.String())
+	}
+	if want := accountUuid.String(); !strings.Contains(w.Body.String(), want) {
+		t.Errorf("Router: body = %q, want it to contain the parent account uuid %q (proving parentEntityUuids was threaded through)", w.Body.String(), want)
+	}
+}
+
+func TestRouterResolvesBarePluralPath(t *testing.T) {
+	withRouterTestCollections(t)
+
+	req := httptest.NewRequest("GET", "/accounts", nil)
+	w := httptest.NewRecorder()
+	Router().ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("Router: status = %d, want 200, body = %q", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "accounts-list") {
+		t.Errorf("Router: body = %q, want the accounts plural handler's response", w.Body.String())
+	}
+}
+
+func TestRouterResolvesBareSingularPath(t *testing.T) {
+	withRouterTestCollections(t)
+
+	accountUuid := uuid.NewV4()
+
+	req := httptest.NewRequest("GET", "/accounts/"+accountUuid.String(), nil)
+	w := httptest.NewRecorder()
+	Router().ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("Router: status = %d, want 200, body = %q", w.Code, w.Body.String())
+	}
+	if want := accountUuid.String(); !strings.Contains(w.Body.String(), want) {
+		t.Errorf("Router: body = %q, want it to contain account uuid %q", w.Body.String(), want)
+	}
+}
+
+func TestRouterRejectsUnknownSegment(t *testing.T) {
+	withRouterTestCollections(t)
+
+	req := httptest.NewRequest("GET", "/bogus", nil)
+	w := httptest.NewRecorder()
+	Router().ServeHTTP(w, req)
+
+	if w.Code != 404 {
+		t.Errorf("Router: status = %d, want 404 for an unregistered path component", w.Code)
+	}
+}
+
+func TestRouterRejectsMalformedUuid(t *testing.T) {
+	withRouterTestCollections(t)
+
+	req := httptest.NewRequest("GET", "/accounts/not-a-uuid", nil)
+	w := httptest.NewRecorder()
+	Router().ServeHTTP(w, req)
+
+	if w.Code != 404 {
+		t.Errorf("Router: status = %d, want 404 for a malformed uuid path component", w.Code)
+	}
+}
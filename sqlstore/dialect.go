@@ -0,0 +1,60 @@
+package sqlstore
+
+import "strconv"
+
+// Dialect abstracts the small amount of SQL syntax that differs between
+// database/sql drivers: how a positional parameter is written, and how
+// an identifier (table or column name) is quoted.
+type Dialect interface {
+	// Placeholder returns the parameter marker for the n'th
+	// (1-indexed) bound argument in a query.
+	Placeholder(n int) string
+
+	// Quote returns identifier quoted for safe use as a table or
+	// column name.
+	Quote(identifier string) string
+}
+
+// escapeIdentifier doubles every occurrence of quoteChar in identifier,
+// the standard SQL way of escaping a quote character embedded in a
+// quoted identifier. Quote callers only ever feed this table/column
+// names from Go struct definitions and s.cols (see FilterableFields),
+// never raw request input, but doubling is cheap defense in depth
+// against an identifier that does contain one.
+func escapeIdentifier(identifier string, quoteChar byte) string {
+	out := make([]byte, 0, len(identifier))
+	for i := 0; i < len(identifier); i++ {
+		if identifier[i] == quoteChar {
+			out = append(out, quoteChar)
+		}
+		out = append(out, identifier[i])
+	}
+	return string(out)
+}
+
+// Postgres is a Dialect for lib/pq, pgx and other Postgres drivers,
+// using "$1", "$2", ... placeholders and double-quoted identifiers.
+type Postgres struct{}
+
+func (Postgres) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+func (Postgres) Quote(identifier string) string {
+	return `"` + escapeIdentifier(identifier, '"') + `"`
+}
+
+// MySQL is a Dialect for go-sql-driver/mysql, using "?" placeholders
+// and backtick-quoted identifiers.
+type MySQL struct{}
+
+func (MySQL) Placeholder(n int) string { return "?" }
+func (MySQL) Quote(identifier string) string {
+	return "`" + escapeIdentifier(identifier, '`') + "`"
+}
+
+// SQLite is a Dialect for mattn/go-sqlite3 and modernc.org/sqlite,
+// using "?" placeholders and double-quoted identifiers.
+type SQLite struct{}
+
+func (SQLite) Placeholder(n int) string { return "?" }
+func (SQLite) Quote(identifier string) string {
+	return `"` + escapeIdentifier(identifier, '"') + `"`
+}
@@ -0,0 +1,28 @@
+package sqlstore
+
+import "testing"
+
+func TestQuoteEscapesEmbeddedQuoteChar(t *testing.T) {
+	cases := []struct {
+		name     string
+		dialect  Dialect
+		ident    string
+		expected string
+	}{
+		{"postgres plain", Postgres{}, "age", `"age"`},
+		{"postgres injection attempt", Postgres{}, `age" = 1 OR "1`, `"age"" = 1 OR ""1"`},
+		{"mysql plain", MySQL{}, "age", "`age`"},
+		{"mysql injection attempt", MySQL{}, "age` = 1 OR `1", "`age`` = 1 OR ``1`"},
+		{"sqlite plain", SQLite{}, "age", `"age"`},
+		{"sqlite injection attempt", SQLite{}, `age" = 1 OR "1`, `"age"" = 1 OR ""1"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := c.dialect.Quote(c.ident)
+			if got != c.expected {
+				t.Errorf("Quote(%q) = %q, want %q", c.ident, got, c.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package sqlstore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/John-Sharp/entitycoll"
+)
+
+// whereClause translates an entitycoll.FilterExpr into a parameterized
+// SQL boolean expression using dialect's placeholders, starting the
+// parameter numbering at argOffset+1. It returns the clause (empty if
+// expr is nil), the arguments to bind, and an error. One placeholder is
+// used per returned argument, so callers composing multiple clauses can
+// keep numbering them in order from len of the arguments.
+func whereClause(expr entitycoll.FilterExpr, dialect Dialect, argOffset int) (string, []interface{}, error) {
+	if expr == nil {
+		return "", nil, nil
+	}
+
+	switch e := expr.(type) {
+	case entitycoll.FilterNode:
+		return nodeClause(e, dialect, argOffset)
+	case entitycoll.FilterGroup:
+		return groupClause(e, dialect, argOffset)
+	default:
+		return "", nil, fmt.Errorf("sqlstore: unsupported filter expression %T", expr)
+	}
+}
+
+func groupClause(g entitycoll.FilterGroup, dialect Dialect, argOffset int) (string, []interface{}, error) {
+	if len(g.Nodes) == 0 {
+		return "", nil, nil
+	}
+
+	joiner := " AND "
+	if g.Bool == entitycoll.Or {
+		joiner = " OR "
+	}
+
+	var parts []string
+	var args []interface{}
+	for _, n := range g.Nodes {
+		clause, nArgs, err := whereClause(n, dialect, argOffset+len(args))
+		if err != nil {
+			return "", nil, err
+		}
+		if clause == "" {
+			continue
+		}
+		parts = append(parts, clause)
+		args = append(args, nArgs...)
+	}
+
+	if len(parts) == 0 {
+		return "", nil, nil
+	}
+	return "(" + strings.Join(parts, joiner) + ")", args, nil
+}
+
+func nodeClause(n entitycoll.FilterNode, dialect Dialect, argOffset int) (string, []interface{}, error) {
+	col := dialect.Quote(n.FieldName)
+
+	switch n.Comp {
+	case entitycoll.NULL:
+		return col + " IS NULL", nil, nil
+	case entitycoll.NOTNULL:
+		return col + " IS NOT NULL", nil, nil
+	case entitycoll.EQ:
+		return col + " = " + dialect.Placeholder(argOffset+1), []interface{}{n.Value}, nil
+	case entitycoll.NEQ:
+		return col + " <> " + dialect.Placeholder(argOffset+1), []interface{}{n.Value}, nil
+	case entitycoll.LT:
+		return col + " < " + dialect.Placeholder(argOffset+1), []interface{}{n.Value}, nil
+	case entitycoll.LTEQ:
+		return col + " <= " + dialect.Placeholder(argOffset+1), []interface{}{n.Value}, nil
+	case entitycoll.GT:
+		return col + " > " + dialect.Placeholder(argOffset+1), []interface{}{n.Value}, nil
+	case entitycoll.GTEQ:
+		return col + " >= " + dialect.Placeholder(argOffset+1), []interface{}{n.Value}, nil
+	case entitycoll.LIKE:
+		return col + " LIKE " + dialect.Placeholder(argOffset+1), []interface{}{n.Value}, nil
+	case entitycoll.IN:
+		if len(n.Values) == 0 {
+			return "1 = 0", nil, nil
+		}
+		placeholders := make([]string, len(n.Values))
+		args := make([]interface{}, len(n.Values))
+		for i, v := range n.Values {
+			placeholders[i] = dialect.Placeholder(argOffset + i + 1)
+			args[i] = v
+		}
+		return col + " IN (" + strings.Join(placeholders, ", ") + ")", args, nil
+	case entitycoll.BETWEEN:
+		if len(n.Values) != 2 {
+			return "", nil, fmt.Errorf("sqlstore: between filter on %q needs exactly 2 values", n.FieldName)
+		}
+		return col + " BETWEEN " + dialect.Placeholder(argOffset+1) + " AND " + dialect.Placeholder(argOffset+2),
+			[]interface{}{n.Values[0], n.Values[1]}, nil
+	default:
+		return "", nil, fmt.Errorf("sqlstore: unsupported comparator %v on field %q", n.Comp, n.FieldName)
+	}
+}
+
+// orderByClause translates filter.Sort into an ORDER BY clause body
+// (without the "ORDER BY" keywords), or "" if there's nothing to sort by.
+func orderByClause(sort []entitycoll.SortObj, dialect Dialect) string {
+	if len(sort) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(sort))
+	for i, s := range sort {
+		dir := "ASC"
+		if s.SortOrder == entitycoll.DESC {
+			dir = "DESC"
+		}
+		parts[i] = dialect.Quote(s.FieldName) + " " + dir
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,94 @@
+package sqlstore
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/satori/go.uuid"
+)
+
+// column describes one field of a registered struct type and the
+// database column it's persisted as.
+type column struct {
+	fieldIndex int
+	name       string
+	isUuid     bool
+}
+
+// columnsOf inspects t (a struct type) for exported fields tagged
+// `db:"colname"`; a field tagged `db:"-"` is skipped. A field named
+// "Uuid" of type uuid.UUID is treated as the primary key unless some
+// other field is explicitly tagged `db:"uuid"`.
+func columnsOf(t reflect.Type) ([]column, error) {
+	var cols []column
+	uuidType := reflect.TypeOf(uuid.UUID{})
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag, ok := f.Tag.Lookup("db")
+		if ok && tag == "-" {
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = f.Name
+		}
+
+		cols = append(cols, column{
+			fieldIndex: i,
+			name:       name,
+			isUuid:     f.Type == uuidType && (name == "uuid" || f.Name == "Uuid"),
+		})
+	}
+
+	// a field explicitly tagged `db:"uuid"` takes precedence over one
+	// merely named Uuid
+	for i := range cols {
+		if cols[i].isUuid && cols[i].name == "uuid" {
+			for j := range cols {
+				cols[j].isUuid = j == i
+			}
+			break
+		}
+	}
+
+	if !hasUuidColumn(cols) {
+		return nil, fmt.Errorf("sqlstore: type %s has no uuid.UUID field tagged or named Uuid to use as primary key", t)
+	}
+
+	return cols, nil
+}
+
+func hasUuidColumn(cols []column) bool {
+	for _, c := range cols {
+		if c.isUuid {
+			return true
+		}
+	}
+	return false
+}
+
+func uuidColumn(cols []column) column {
+	for _, c := range cols {
+		if c.isUuid {
+			return c
+		}
+	}
+	panic("sqlstore: uuidColumn called without a uuid column present")
+}
+
+// scanDestinations returns, for each column in cols, a pointer into v
+// (addressable, a struct of the registered type) suitable for passing
+// to sql.Rows.Scan.
+func scanDestinations(v reflect.Value, cols []column) []interface{} {
+	dests := make([]interface{}, len(cols))
+	for i, c := range cols {
+		dests[i] = v.Field(c.fieldIndex).Addr().Interface()
+	}
+	return dests
+}
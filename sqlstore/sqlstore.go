@@ -0,0 +1,286 @@
+// Package sqlstore provides a generic entitycoll.EntityCollection
+// implementation backed by database/sql, so that a consumer of
+// entitycoll can get a working CRUD collection by registering a struct
+// with `db` tags instead of writing persistence code by hand.
+package sqlstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/John-Sharp/entitycoll"
+	"github.com/satori/go.uuid"
+)
+
+// SQLEntityCollection is a generic entitycoll.EntityCollection backed
+// by a single table, reached through database/sql. T is the Go struct
+// representing a row; its exported fields are mapped to columns via
+// `db:"colname"` tags (falling back to the field name), and exactly one
+// field of type uuid.UUID - named Uuid or tagged `db:"uuid"` - is used
+// as the primary key.
+type SQLEntityCollection[T any] struct {
+	DB      *sql.DB
+	Dialect Dialect
+	Table   string
+
+	restName string
+	parent   entitycoll.EntityCollection
+	// ParentKeyColumn is the column holding the parent entity's uuid,
+	// used to scope CreateEntity/GetCollection when the collection has a
+	// parent.
+	ParentKeyColumn string
+
+	typ  reflect.Type
+	cols []column
+}
+
+// New builds a SQLEntityCollection for T, backed by table via db, using
+// dialect's SQL syntax. restName is the REST path segment this
+// collection is served under (see entitycoll.EntityCollection.GetRestName).
+// parent may be nil for a top-level collection; when non-nil,
+// parentKeyColumn must name the column that stores the parent's uuid.
+func New[T any](db *sql.DB, dialect Dialect, table, restName string, parent entitycoll.EntityCollection, parentKeyColumn string) (*SQLEntityCollection[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sqlstore: %s is not a struct type", typ)
+	}
+
+	cols, err := columnsOf(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLEntityCollection[T]{
+		DB:              db,
+		Dialect:         dialect,
+		Table:           table,
+		restName:        restName,
+		parent:          parent,
+		ParentKeyColumn: parentKeyColumn,
+		typ:             typ,
+		cols:            cols,
+	}, nil
+}
+
+func (s *SQLEntityCollection[T]) GetRestName() string {
+	return s.restName
+}
+
+func (s *SQLEntityCollection[T]) GetParentCollection() entitycoll.EntityCollection {
+	return s.parent
+}
+
+// FilterableFields implements entitycoll.FilterSchemaProvider, so that
+// getPluralHandler rejects a filter or sort referencing a field that
+// isn't one of T's mapped columns with 400 before it ever reaches
+// whereClause/orderByClause -- those turn FieldName straight into a
+// quoted SQL identifier, so only T's own column names reach the query.
+func (s *SQLEntityCollection[T]) FilterableFields() map[string]bool {
+	fields := make(map[string]bool, len(s.cols))
+	for _, c := range s.cols {
+		fields[c.name] = true
+	}
+	return fields
+}
+
+// CreateEntity unmarshals body into a new T, assigns it a fresh uuid,
+// inserts it (scoped to parentEntityUuids[s.parent.GetRestName()] when
+// s.parent is set) and returns its REST path, prefixed with the name and
+// uuid of each ancestor collection.
+func (s *SQLEntityCollection[T]) CreateEntity(requestor entitycoll.Entity, parentEntityUuids map[string]uuid.UUID, body []byte) (string, error) {
+	v := reflect.New(s.typ)
+	if err := json.Unmarshal(body, v.Interface()); err != nil {
+		return "", fmt.Errorf("sqlstore: decoding entity body: %w", err)
+	}
+
+	newUuid := uuid.NewV4()
+	v.Elem().Field(uuidColumn(s.cols).fieldIndex).Set(reflect.ValueOf(newUuid))
+
+	colNames := make([]string, len(s.cols))
+	placeholders := make([]string, len(s.cols))
+	args := make([]interface{}, len(s.cols))
+	for i, c := range s.cols {
+		colNames[i] = s.Dialect.Quote(c.name)
+		placeholders[i] = s.Dialect.Placeholder(i + 1)
+		args[i] = v.Elem().Field(c.fieldIndex).Interface()
+	}
+
+	if s.parent != nil {
+		parentUuid, ok := parentEntityUuids[s.parent.GetRestName()]
+		if !ok {
+			return "", fmt.Errorf("sqlstore: missing parent uuid for %q", s.parent.GetRestName())
+		}
+		colNames = append(colNames, s.Dialect.Quote(s.ParentKeyColumn))
+		placeholders = append(placeholders, s.Dialect.Placeholder(len(placeholders)+1))
+		args = append(args, parentUuid)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		s.Dialect.Quote(s.Table), strings.Join(colNames, ", "), strings.Join(placeholders, ", "))
+
+	if _, err := s.DB.Exec(query, args...); err != nil {
+		return "", fmt.Errorf("sqlstore: inserting entity: %w", err)
+	}
+
+	entityPath := "/" + s.restName + "/" + newUuid.String()
+	for c := s.parent; c != nil; c = c.GetParentCollection() {
+		entityPath = "/" + c.GetRestName() + "/" + parentEntityUuids[c.GetRestName()].String() + entityPath
+	}
+	return entityPath, nil
+}
+
+// GetEntity returns the row with the given uuid as a *T.
+func (s *SQLEntityCollection[T]) GetEntity(targetUuid uuid.UUID) (entitycoll.Entity, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s",
+		s.selectColumns(), s.Dialect.Quote(s.Table), s.Dialect.Quote(uuidColumn(s.cols).name), s.Dialect.Placeholder(1))
+
+	row := s.DB.QueryRow(query, targetUuid)
+
+	v := reflect.New(s.typ)
+	if err := row.Scan(scanDestinations(v.Elem(), s.cols)...); err != nil {
+		return nil, fmt.Errorf("sqlstore: getting entity: %w", err)
+	}
+	return v.Interface(), nil
+}
+
+// GetCollection returns the rows scoped to parentEntityUuids and
+// matching filter, translating filter.Filter, filter.Sort and
+// filter.Page/Count into a single parameterized SQL query (plus a
+// COUNT(*) query, sharing the same WHERE clause, for TotalEntities).
+func (s *SQLEntityCollection[T]) GetCollection(parentEntityUuids map[string]uuid.UUID, filter entitycoll.CollFilter) (entitycoll.Collection, error) {
+	where, args, err := s.whereForCollection(parentEntityUuids, filter)
+	if err != nil {
+		return entitycoll.Collection{}, err
+	}
+
+	total, err := s.countMatching(where, args)
+	if err != nil {
+		return entitycoll.Collection{}, err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", s.selectColumns(), s.Dialect.Quote(s.Table))
+	if where != "" {
+		query += " WHERE " + where
+	}
+	if orderBy := orderByClause(filter.Sort, s.Dialect); orderBy != "" {
+		query += " ORDER BY " + orderBy
+	}
+	if filter.Count != nil {
+		query += " LIMIT " + strconv.FormatUint(*filter.Count, 10)
+		if filter.Page != nil {
+			offset := *filter.Page * int64(*filter.Count)
+			query += " OFFSET " + strconv.FormatInt(offset, 10)
+		}
+	}
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return entitycoll.Collection{}, fmt.Errorf("sqlstore: querying collection: %w", err)
+	}
+	defer rows.Close()
+
+	var entities []entitycoll.Entity
+	for rows.Next() {
+		v := reflect.New(s.typ)
+		if err := rows.Scan(scanDestinations(v.Elem(), s.cols)...); err != nil {
+			return entitycoll.Collection{}, fmt.Errorf("sqlstore: scanning row: %w", err)
+		}
+		entities = append(entities, v.Interface())
+	}
+	if err := rows.Err(); err != nil {
+		return entitycoll.Collection{}, err
+	}
+
+	return entitycoll.Collection{TotalEntities: total, Entities: entities}, nil
+}
+
+// EditEntity decodes body into a T and overwrites every non-uuid
+// column of the row with the given uuid.
+func (s *SQLEntityCollection[T]) EditEntity(targetUuid uuid.UUID, body []byte) error {
+	v := reflect.New(s.typ)
+	if err := json.Unmarshal(body, v.Interface()); err != nil {
+		return fmt.Errorf("sqlstore: decoding entity body: %w", err)
+	}
+
+	var sets []string
+	var args []interface{}
+	for _, c := range s.cols {
+		if c.isUuid {
+			continue
+		}
+		sets = append(sets, s.Dialect.Quote(c.name)+" = "+s.Dialect.Placeholder(len(args)+1))
+		args = append(args, v.Elem().Field(c.fieldIndex).Interface())
+	}
+	args = append(args, targetUuid)
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s",
+		s.Dialect.Quote(s.Table), strings.Join(sets, ", "), s.Dialect.Quote(uuidColumn(s.cols).name), s.Dialect.Placeholder(len(args)))
+
+	if _, err := s.DB.Exec(query, args...); err != nil {
+		return fmt.Errorf("sqlstore: updating entity: %w", err)
+	}
+	return nil
+}
+
+// DelEntity deletes the row with the given uuid.
+func (s *SQLEntityCollection[T]) DelEntity(targetUuid uuid.UUID) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = %s",
+		s.Dialect.Quote(s.Table), s.Dialect.Quote(uuidColumn(s.cols).name), s.Dialect.Placeholder(1))
+
+	if _, err := s.DB.Exec(query, targetUuid); err != nil {
+		return fmt.Errorf("sqlstore: deleting entity: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLEntityCollection[T]) selectColumns() string {
+	names := make([]string, len(s.cols))
+	for i, c := range s.cols {
+		names[i] = s.Dialect.Quote(c.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// whereForCollection builds the combined WHERE clause (parent FK scope
+// AND the filter AST) and its bound arguments for GetCollection.
+func (s *SQLEntityCollection[T]) whereForCollection(parentEntityUuids map[string]uuid.UUID, filter entitycoll.CollFilter) (string, []interface{}, error) {
+	var clauses []string
+	var args []interface{}
+
+	if s.parent != nil {
+		parentUuid, ok := parentEntityUuids[s.parent.GetRestName()]
+		if !ok {
+			return "", nil, fmt.Errorf("sqlstore: missing parent uuid for %q", s.parent.GetRestName())
+		}
+		clauses = append(clauses, s.Dialect.Quote(s.ParentKeyColumn)+" = "+s.Dialect.Placeholder(len(args)+1))
+		args = append(args, parentUuid)
+	}
+
+	filterClause, filterArgs, err := whereClause(filter.Filter, s.Dialect, len(args))
+	if err != nil {
+		return "", nil, err
+	}
+	if filterClause != "" {
+		clauses = append(clauses, filterClause)
+		args = append(args, filterArgs...)
+	}
+
+	return strings.Join(clauses, " AND "), args, nil
+}
+
+func (s *SQLEntityCollection[T]) countMatching(where string, args []interface{}) (uint, error) {
+	query := "SELECT COUNT(*) FROM " + s.Dialect.Quote(s.Table)
+	if where != "" {
+		query += " WHERE " + where
+	}
+
+	var total uint
+	if err := s.DB.QueryRow(query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("sqlstore: counting collection: %w", err)
+	}
+	return total, nil
+}